@@ -8,25 +8,51 @@ import (
 	"fmt"
 	"net/url"
 	"os"
-	"strings"
+	"runtime"
 	"time"
 
-	"github.com/jackc/pgx/v4"
-	"github.com/ministryofjustice/opg-search-service/internal/firm"
+	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/ministryofjustice/opg-search-service/internal/index"
-	"github.com/ministryofjustice/opg-search-service/internal/person"
+	"github.com/ministryofjustice/opg-search-service/internal/index/bleve"
+	"github.com/ministryofjustice/opg-search-service/internal/index/checkpoint"
+	"github.com/ministryofjustice/opg-search-service/internal/index/manifest"
+	"github.com/ministryofjustice/opg-search-service/internal/index/meilisearch"
+	"github.com/ministryofjustice/opg-search-service/internal/index/opensearch"
+	"github.com/ministryofjustice/opg-search-service/internal/index/registry"
 	"github.com/sirupsen/logrus"
+
+	// Blank-imported for their init() side effect of registering themselves
+	// with the registry package.
+	_ "github.com/ministryofjustice/opg-search-service/internal/firm"
+	_ "github.com/ministryofjustice/opg-search-service/internal/person"
 )
 
+// entityConfigVersions distinguishes index names built for different
+// engines, and within an engine, for different mapping revisions of the
+// same entity's config. Each engine has its own version number so that two
+// engines sharing byte-identical ConfigFunc output never hash to the same
+// index name. Bump an engine's entry when its interpretation of
+// ConfigFunc's output changes in a way that isn't already reflected by the
+// config hash.
+var entityConfigVersions = map[string]int{
+	"opensearch":  1,
+	"bleve":       2,
+	"meilisearch": 3,
+}
+
 type Secrets interface {
 	GetGlobalSecretString(key string) (string, error)
 }
 
+// defaultEngine is used when neither -engine nor SEARCH_SERVICE_ENGINE is set,
+// preserving the previous OpenSearch-only behaviour.
+const defaultEngine = "opensearch"
+
 type IndexCommand struct {
-	logger            *logrus.Logger
-	esClient          index.BulkClient
-	secrets           Secrets
-	currentIndexNames []string
+	logger   *logrus.Logger
+	secrets  Secrets
+	entities []registry.Entity
+	indexes  []IndexConfig
 }
 
 type IndexConfig struct {
@@ -38,34 +64,62 @@ type IndexConfig struct {
 
 	// configuration for the index
 	Config []byte
+
+	// EngineVersion distinguishes index names built for different engines
+	// from the same Config, so e.g. an OpenSearch mapping and a bleve
+	// mapping derived from the same source config don't hash to the same
+	// index name.
+	EngineVersion int
 }
 
-func NewIndexConfig(configFunc func() ([]byte, error), alias string, l *logrus.Logger) IndexConfig {
+func NewIndexConfig(configFunc func() ([]byte, error), alias string, engineVersion int, l *logrus.Logger) IndexConfig {
 	config, err := configFunc()
 	if err != nil {
 		l.Fatal(err)
 	}
 
 	sum := sha256.Sum256(config)
-	indexName := fmt.Sprintf("%s_%x", alias, sum[:8])
+	indexName := fmt.Sprintf("%s_v%d_%x", alias, engineVersion, sum[:8])
 
 	return IndexConfig{
-		Name:   indexName,
-		Alias:  alias,
-		Config: config,
+		Name:          indexName,
+		Alias:         alias,
+		Config:        config,
+		EngineVersion: engineVersion,
 	}
 }
 
-func NewIndex(logger *logrus.Logger, esClient index.BulkClient, secrets Secrets, indexes []IndexConfig) *IndexCommand {
-	var indexNames []string
-	for _, indexConfig := range indexes {
-		indexNames = append(indexNames, indexConfig.Name)
-	}
+func NewIndex(logger *logrus.Logger, secrets Secrets, entities []registry.Entity) *IndexCommand {
 	return &IndexCommand{
-		logger:            logger,
-		esClient:          esClient,
-		secrets:           secrets,
-		currentIndexNames: indexNames,
+		logger:   logger,
+		secrets:  secrets,
+		entities: entities,
+	}
+}
+
+// newEngine constructs the index.Engine selected by name, reading any
+// backend-specific connection settings from the environment.
+func newEngine(name string) (index.Engine, error) {
+	switch name {
+	case "", "opensearch":
+		return opensearch.New(opensearch.Config{
+			URL:      os.Getenv("SEARCH_SERVICE_OPENSEARCH_URL"),
+			Username: os.Getenv("SEARCH_SERVICE_OPENSEARCH_USERNAME"),
+			Password: os.Getenv("SEARCH_SERVICE_OPENSEARCH_PASSWORD"),
+		}), nil
+	case "bleve":
+		dir := os.Getenv("SEARCH_SERVICE_BLEVE_DIR")
+		if dir == "" {
+			dir = "./data/bleve"
+		}
+		return bleve.New(bleve.Config{Dir: dir}), nil
+	case "meilisearch":
+		return meilisearch.New(meilisearch.Config{
+			URL:    os.Getenv("SEARCH_SERVICE_MEILISEARCH_URL"),
+			APIKey: os.Getenv("SEARCH_SERVICE_MEILISEARCH_KEY"),
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown -engine %q", name)
 	}
 }
 
@@ -77,17 +131,30 @@ func (c *IndexCommand) Run(args []string) error {
 	flagset := flag.NewFlagSet("index", flag.ExitOnError)
 
 	all := flagset.Bool("all", false, "index all records for chosen indices")
-	firmOnly := flagset.Bool("firm", false, "index records to the firm index")
-	personOnly := flagset.Bool("person", false, "index records to the person index")
+	only := make(map[string]*bool, len(c.entities))
+	for _, e := range c.entities {
+		only[e.Alias()] = flagset.Bool(e.FlagName(), false, fmt.Sprintf("index records to the %s index", e.Alias()))
+	}
 	from := flagset.Int("from", 0, "index an id range starting from (use with -to)")
 	to := flagset.Int("to", 100, "index an id range ending at (use with -from)")
 	batchSize := flagset.Int("batch-size", 10000, "batch size to read from db")
 	fromDate := flagset.String("from-date", "", "index records updated from this date")
+	engineName := flagset.String("engine", os.Getenv("SEARCH_SERVICE_ENGINE"), "search engine to index into: opensearch, bleve or meilisearch")
+	reindex := flagset.Bool("reindex", false, "cut the alias over to the new index config with a zero-downtime blue/green swap; live writes reach the pending generation via the watch command's own dual-write, independent of this flag")
+	keepPrevious := flagset.Int("keep-previous", 1, "number of retired generations to keep in the manifest for rollback, per alias")
+	grace := flagset.Duration("grace", 24*time.Hour, "how long a retired generation stays eligible for rollback before it can be pruned")
+	workers := flagset.Int("workers", runtime.GOMAXPROCS(0), "number of id-range shards to index concurrently, for -all and -from/-to")
+	restart := flagset.Bool("restart", false, "ignore existing checkpoints and index -all or -from/-to from the beginning")
+	rateLimit := flagset.Float64("rate-limit", 0, "maximum docs/sec written to the engine across all workers, 0 for unlimited")
 
 	if err := flagset.Parse(args); err != nil {
 		return err
 	}
 
+	if *engineName == "" {
+		*engineName = defaultEngine
+	}
+
 	ctx := context.Background()
 
 	connString, err := c.dbConnectionString()
@@ -95,34 +162,83 @@ func (c *IndexCommand) Run(args []string) error {
 		return err
 	}
 
-	conn, err := pgx.Connect(ctx, connString)
+	// A pool, not a single *pgx.Conn, because indexing shards the id range
+	// across opts.Workers goroutines that read the DataSource and write
+	// checkpoints concurrently, and pgx.Conn isn't safe for concurrent use.
+	pool, err := pgxpool.Connect(ctx, connString)
 	if err != nil {
 		return err
 	}
-	defer conn.Close(ctx) //nolint:errcheck // no need to check error when closing DB connection
+	defer pool.Close()
 
-	if err := conn.Ping(ctx); err != nil {
+	if err := pool.Ping(ctx); err != nil {
 		return err
 	}
 
-	indexers := map[string]*index.Indexer{}
-	noneSet := !*firmOnly && !*personOnly
+	engine, err := newEngine(*engineName)
+	if err != nil {
+		return err
+	}
+	defer engine.Close() //nolint:errcheck // no need to check error when closing the engine
 
-	if *firmOnly || noneSet {
-		for _, indexName := range c.currentIndexNames {
-			if strings.HasPrefix(indexName, "firm_") {
-				indexers["firm"] = index.New(c.esClient, c.logger, firm.NewDB(conn), indexName)
-				break
-			}
+	engineVersion, ok := entityConfigVersions[*engineName]
+	if !ok {
+		return fmt.Errorf("unknown -engine %q", *engineName)
+	}
+
+	indexes := make([]IndexConfig, 0, len(c.entities))
+	indexByAlias := make(map[string]IndexConfig, len(c.entities))
+	for _, e := range c.entities {
+		indexConfig := NewIndexConfig(e.ConfigFunc(), e.Alias(), engineVersion, c.logger)
+		indexes = append(indexes, indexConfig)
+		indexByAlias[e.Alias()] = indexConfig
+	}
+	c.indexes = indexes
+
+	for _, indexConfig := range c.indexes {
+		if err := engine.Init(ctx, indexConfig.Name, indexConfig.Config); err != nil {
+			return fmt.Errorf("%s: %w", indexConfig.Name, err)
 		}
 	}
-	if *personOnly || noneSet {
-		for _, indexName := range c.currentIndexNames {
-			if strings.HasPrefix(indexName, "person_") {
-				indexers["person"] = index.New(c.esClient, c.logger, person.NewDB(conn), indexName)
-				break
+
+	noneSet := true
+	for _, selected := range only {
+		if *selected {
+			noneSet = false
+			break
+		}
+	}
+
+	if *reindex {
+		if !engine.SupportsAliasSwap() {
+			return fmt.Errorf("-reindex: -engine=%s has no alias swap support, so the backfilled generation could never be cut over", *engineName)
+		}
+
+		selected := make(map[string]bool, len(only))
+		if !noneSet {
+			for alias, flagVal := range only {
+				if *flagVal {
+					selected[alias] = true
+				}
 			}
 		}
+		return c.reindex(ctx, pool, engine, manifest.NewPostgresStore(pool), reindexOptions{
+			only:         selected,
+			batchSize:    *batchSize,
+			keepPrevious: *keepPrevious,
+			grace:        *grace,
+			workers:      *workers,
+			restart:      *restart,
+			rateLimit:    *rateLimit,
+		})
+	}
+
+	indexers := map[string]*index.Indexer{}
+	for _, e := range c.entities {
+		if !noneSet && !*only[e.Alias()] {
+			continue
+		}
+		indexers[e.Alias()] = index.New(engine, c.logger, e.NewSource(pool), indexByAlias[e.Alias()].Name)
 	}
 
 	fromTime, err := time.Parse(time.RFC3339, *fromDate)
@@ -131,18 +247,29 @@ func (c *IndexCommand) Run(args []string) error {
 		return fmt.Errorf("-from-date: %w", err)
 	}
 
+	checkpoints := checkpoint.NewPostgresStore(pool)
+
 	for indexerName, indexer := range indexers {
 		var result *index.Result
 
+		runOpts := index.RunOptions{
+			Name:        indexByAlias[indexerName].Name,
+			Workers:     *workers,
+			BatchSize:   *batchSize,
+			Restart:     *restart,
+			RateLimit:   *rateLimit,
+			Checkpoints: checkpoints,
+		}
+
 		if !fromTime.IsZero() {
 			c.logger.Printf("indexing %s by date from=%v batchSize=%d", indexerName, fromTime, *batchSize)
 			result, err = indexer.FromDate(ctx, fromTime, *batchSize)
 		} else if *all {
-			c.logger.Printf("indexing %s all records batchSize=%d", indexerName, *batchSize)
-			result, err = indexer.All(ctx, *batchSize)
+			c.logger.Printf("indexing %s all records workers=%d batchSize=%d restart=%v", indexerName, runOpts.Workers, *batchSize, *restart)
+			result, err = indexer.All(ctx, runOpts)
 		} else {
-			c.logger.Printf("indexing %s by id from=%d to=%d batchSize=%d", indexerName, *from, *to, *batchSize)
-			result, err = indexer.ByID(ctx, *from, *to, *batchSize)
+			c.logger.Printf("indexing %s by id from=%d to=%d workers=%d batchSize=%d restart=%v", indexerName, *from, *to, runOpts.Workers, *batchSize, *restart)
+			result, err = indexer.ByID(ctx, *from, *to, runOpts)
 		}
 
 		if err != nil {
@@ -159,10 +286,16 @@ func (c *IndexCommand) Run(args []string) error {
 }
 
 func (c *IndexCommand) dbConnectionString() (string, error) {
+	return dbConnectionString(c.secrets)
+}
+
+// dbConnectionString builds the Postgres connection string shared by every
+// command that talks to the database directly (IndexCommand, WatchCommand).
+func dbConnectionString(secrets Secrets) (string, error) {
 	pass := os.Getenv("SEARCH_SERVICE_DB_PASS")
 	if passSecret := os.Getenv("SEARCH_SERVICE_DB_PASS_SECRET"); passSecret != "" {
 		var err error
-		pass, err = c.secrets.GetGlobalSecretString(passSecret)
+		pass, err = secrets.GetGlobalSecretString(passSecret)
 		if err != nil {
 			return "", err
 		}
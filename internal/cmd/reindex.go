@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/ministryofjustice/opg-search-service/internal/index"
+	"github.com/ministryofjustice/opg-search-service/internal/index/checkpoint"
+	"github.com/ministryofjustice/opg-search-service/internal/index/manifest"
+	"github.com/ministryofjustice/opg-search-service/internal/index/registry"
+)
+
+type reindexOptions struct {
+	// only restricts the reindex to these aliases; empty means every entity.
+	only         map[string]bool
+	batchSize    int
+	keepPrevious int
+	grace        time.Duration
+	workers      int
+	restart      bool
+	rateLimit    float64
+}
+
+// reindex runs the blue/green workflow for every IndexConfig whose alias
+// doesn't yet point at it: create the new index (already done by the
+// caller's engine.Init loop), run the batch backfill (live writes in the
+// meantime reach the pending generation too, via WatchCommand's own
+// dual-write of its CDC stream), then perform a single atomic alias swap
+// and retain the previous generation for opts.grace so RollbackCommand can
+// flip back to it.
+func (c *IndexCommand) reindex(ctx context.Context, pool *pgxpool.Pool, engine index.Engine, store manifest.Store, opts reindexOptions) error {
+	for _, indexConfig := range c.indexes {
+		if len(opts.only) > 0 && !opts.only[indexConfig.Alias] {
+			continue
+		}
+
+		if err := c.reindexOne(ctx, pool, engine, store, indexConfig, opts); err != nil {
+			return fmt.Errorf("%s: %w", indexConfig.Alias, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *IndexCommand) reindexOne(ctx context.Context, pool *pgxpool.Pool, engine index.Engine, store manifest.Store, indexConfig IndexConfig, opts reindexOptions) error {
+	current, hasCurrent, err := store.Current(ctx, indexConfig.Alias)
+	if err != nil {
+		return err
+	}
+
+	if !hasCurrent {
+		c.logger.Printf("reindex %s: no existing generation, activating %s directly", indexConfig.Alias, indexConfig.Name)
+		if err := c.backfill(ctx, pool, engine, indexConfig, opts); err != nil {
+			return err
+		}
+		if err := engine.AliasSwap(ctx, indexConfig.Alias, indexConfig.Name, nil); err != nil {
+			return fmt.Errorf("alias swap: %w", err)
+		}
+		return store.Activate(ctx, indexConfig.Alias, indexConfig.Name, opts.grace)
+	}
+
+	if current == indexConfig.Name {
+		c.logger.Printf("reindex %s: %s is already active, nothing to do", indexConfig.Alias, indexConfig.Name)
+		return nil
+	}
+
+	c.logger.Printf("reindex %s: %s -> %s", indexConfig.Alias, current, indexConfig.Name)
+
+	if err := store.BeginReindex(ctx, indexConfig.Alias, indexConfig.Name); err != nil {
+		return err
+	}
+
+	if err := c.backfill(ctx, pool, engine, indexConfig, opts); err != nil {
+		return fmt.Errorf("backfill: %w", err)
+	}
+
+	if err := engine.AliasSwap(ctx, indexConfig.Alias, indexConfig.Name, []string{current}); err != nil {
+		return fmt.Errorf("alias swap: %w", err)
+	}
+
+	if err := store.Activate(ctx, indexConfig.Alias, indexConfig.Name, opts.grace); err != nil {
+		return fmt.Errorf("activate: %w", err)
+	}
+
+	pruned, err := store.Prune(ctx, indexConfig.Alias, opts.keepPrevious)
+	if err != nil {
+		return fmt.Errorf("prune: %w", err)
+	}
+	for _, name := range pruned {
+		if err := engine.Delete(ctx, name); err != nil {
+			c.logger.Printf("reindex %s: delete pruned generation %s: %v (delete it manually to reclaim storage)", indexConfig.Alias, name, err)
+		}
+	}
+
+	c.logger.Printf("reindex %s: alias now points at %s, previous generation %s retained for %s", indexConfig.Alias, indexConfig.Name, current, opts.grace)
+	return nil
+}
+
+func (c *IndexCommand) backfill(ctx context.Context, pool *pgxpool.Pool, engine index.Engine, indexConfig IndexConfig, opts reindexOptions) error {
+	entity, ok := registry.Get(indexConfig.Alias)
+	if !ok {
+		return fmt.Errorf("no entity registered for alias %s", indexConfig.Alias)
+	}
+
+	indexer := index.New(engine, c.logger, entity.NewSource(pool), indexConfig.Name)
+
+	result, err := indexer.All(ctx, index.RunOptions{
+		Name:        indexConfig.Name,
+		Workers:     opts.workers,
+		BatchSize:   opts.batchSize,
+		Restart:     opts.restart,
+		RateLimit:   opts.rateLimit,
+		Checkpoints: checkpoint.NewPostgresStore(pool),
+	})
+	if err != nil {
+		return err
+	}
+
+	c.logger.Printf("backfilled %s successful=%d failed=%d", indexConfig.Name, result.Successful, result.Failed)
+	for _, e := range result.Errors {
+		c.logger.Println(e)
+	}
+	return nil
+}
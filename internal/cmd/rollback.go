@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/ministryofjustice/opg-search-service/internal/index/manifest"
+	"github.com/sirupsen/logrus"
+)
+
+// RollbackCommand flips an alias back to the generation it pointed at
+// before the most recent reindex, using the manifest persisted by
+// IndexCommand's -reindex workflow.
+type RollbackCommand struct {
+	logger  *logrus.Logger
+	secrets Secrets
+}
+
+func NewRollback(logger *logrus.Logger, secrets Secrets) *RollbackCommand {
+	return &RollbackCommand{logger: logger, secrets: secrets}
+}
+
+func (c *RollbackCommand) Info() (name, description string) {
+	return "rollback", "flip an alias back to the previous index generation recorded in the manifest"
+}
+
+func (c *RollbackCommand) Run(args []string) error {
+	flagset := flag.NewFlagSet("rollback", flag.ExitOnError)
+
+	alias := flagset.String("alias", "", "alias to roll back (required)")
+	engineName := flagset.String("engine", os.Getenv("SEARCH_SERVICE_ENGINE"), "search engine the alias lives on")
+
+	if err := flagset.Parse(args); err != nil {
+		return err
+	}
+	if *alias == "" {
+		return errors.New("-alias is required")
+	}
+	if *engineName == "" {
+		*engineName = defaultEngine
+	}
+
+	ctx := context.Background()
+
+	connString, err := dbConnectionString(c.secrets)
+	if err != nil {
+		return err
+	}
+
+	pool, err := pgxpool.Connect(ctx, connString)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	engine, err := newEngine(*engineName)
+	if err != nil {
+		return err
+	}
+	defer engine.Close() //nolint:errcheck // no need to check error when closing the engine
+
+	store := manifest.NewPostgresStore(pool)
+
+	history, err := store.History(ctx, *alias)
+	if err != nil {
+		return err
+	}
+
+	var current, previous *manifest.Entry
+	for i := range history {
+		switch history[i].Status {
+		case manifest.StatusActive:
+			current = &history[i]
+		case manifest.StatusRetired:
+			if previous == nil {
+				previous = &history[i]
+			}
+		}
+	}
+
+	if current == nil || previous == nil {
+		return fmt.Errorf("no previous generation recorded for alias %s to roll back to", *alias)
+	}
+
+	if err := engine.AliasSwap(ctx, *alias, previous.Name, []string{current.Name}); err != nil {
+		return fmt.Errorf("roll back %s: %w", *alias, err)
+	}
+
+	if err := store.Activate(ctx, *alias, previous.Name, 0); err != nil {
+		return err
+	}
+
+	c.logger.Printf("rolled back alias=%s from=%s to=%s", *alias, current.Name, previous.Name)
+	return nil
+}
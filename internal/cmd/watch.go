@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sirupsen/logrus"
+
+	"github.com/ministryofjustice/opg-search-service/internal/index"
+	"github.com/ministryofjustice/opg-search-service/internal/index/manifest"
+	"github.com/ministryofjustice/opg-search-service/internal/index/registry"
+	"github.com/ministryofjustice/opg-search-service/internal/index/stream"
+)
+
+// WatchCommand runs the indexer as a long-lived process, applying Postgres
+// logical replication events to the search index as they happen instead of
+// waiting for the next -from-date catch-up run.
+type WatchCommand struct {
+	logger  *logrus.Logger
+	secrets Secrets
+	indexes []IndexConfig
+}
+
+func NewWatch(logger *logrus.Logger, secrets Secrets, indexes []IndexConfig) *WatchCommand {
+	return &WatchCommand{
+		logger:  logger,
+		secrets: secrets,
+		indexes: indexes,
+	}
+}
+
+func (c *WatchCommand) Info() (name, description string) {
+	return "watch", "continuously index changes via postgres logical replication, falling back to -from-date polling if no slot is available"
+}
+
+func (c *WatchCommand) Run(args []string) error {
+	flagset := flag.NewFlagSet("watch", flag.ExitOnError)
+
+	engineName := flagset.String("engine", os.Getenv("SEARCH_SERVICE_ENGINE"), "search engine to index into: opensearch, bleve or meilisearch")
+	slotName := flagset.String("slot-name", "opg_search_service", "postgres logical replication slot to consume")
+	publication := flagset.String("publication", "opg_search_service", "postgres publication to subscribe to")
+	pollEvery := flagset.Duration("poll-every", time.Minute, "how often to poll via -from-date when replication is unavailable")
+	batchSize := flagset.Int("batch-size", 10000, "batch size to read from db during fallback polling")
+
+	if err := flagset.Parse(args); err != nil {
+		return err
+	}
+	if *engineName == "" {
+		*engineName = defaultEngine
+	}
+
+	ctx := context.Background()
+
+	connString, err := dbConnectionString(c.secrets)
+	if err != nil {
+		return err
+	}
+
+	pool, err := pgxpool.Connect(ctx, connString)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	store := manifest.NewPostgresStore(pool)
+
+	engine, err := newEngine(*engineName)
+	if err != nil {
+		return err
+	}
+	defer engine.Close() //nolint:errcheck // no need to check error when closing the engine
+
+	// pgx/v5's pgconn is used here, not the v4 pool the rest of this file
+	// uses, because stream.Subscriber is built on pglogrepl, which only
+	// supports v5.
+	replConn, err := pgconn.Connect(ctx, connString+"?replication=database")
+	if err != nil {
+		c.logger.Printf("replication connection unavailable, falling back to polling: %v", err)
+		return c.pollLoop(ctx, pool, engine, *pollEvery, *batchSize)
+	}
+	defer replConn.Close(ctx) //nolint:errcheck // no need to check error when closing DB connection
+
+	tables, err := c.tables(ctx, engine, store)
+	if err != nil {
+		return err
+	}
+	sub := stream.NewSubscriber(replConn, *slotName, *publication, tables, c.logger)
+
+	if err := sub.EnsureSlotAndPublication(ctx); err != nil {
+		c.logger.Printf("replication slot/publication unavailable, falling back to polling: %v", err)
+		return c.pollLoop(ctx, pool, engine, *pollEvery, *batchSize)
+	}
+
+	c.logger.Printf("watching for changes via replication slot=%s publication=%s", *slotName, *publication)
+	return sub.Run(ctx)
+}
+
+// tables lists the replicated tables and how their row changes map onto
+// documents in the target index, for every alias with a registered entity.
+// Writes always go to the alias's current active generation first, so live
+// traffic keeps reaching the index actually serving queries; if a
+// blue/green reindex is in progress, writes are dual-written into its
+// pending generation too, so that generation is caught up by the time the
+// backfill finishes and the alias is swapped.
+func (c *WatchCommand) tables(ctx context.Context, engine index.Engine, store manifest.Store) ([]stream.Table, error) {
+	var tables []stream.Table
+
+	for _, indexConfig := range c.indexes {
+		entity, ok := registry.Get(indexConfig.Alias)
+		if !ok {
+			continue
+		}
+
+		target := indexConfig.Name
+		if current, ok, err := store.Current(ctx, indexConfig.Alias); err != nil {
+			return nil, fmt.Errorf("%s: check current generation: %w", indexConfig.Alias, err)
+		} else if ok {
+			target = current
+		}
+
+		tableEngine := engine
+		if pending, ok, err := store.Pending(ctx, indexConfig.Alias); err != nil {
+			return nil, fmt.Errorf("%s: check pending reindex: %w", indexConfig.Alias, err)
+		} else if ok && pending == indexConfig.Name && pending != target {
+			tableEngine = index.DualWrite(engine, indexConfig.Name)
+		}
+
+		tables = append(tables, stream.Table{Name: entity.TableName(), Engine: tableEngine, Index: target, ToDoc: columnsToDoc})
+	}
+
+	return tables, nil
+}
+
+// columnsToDoc is a generic RowChange -> index.Doc mapping, keyed on the
+// replicated table's "id" column, good enough until firm/person grow a
+// dedicated document shape for streamed changes.
+func columnsToDoc(change stream.RowChange) (index.Doc, bool, error) {
+	if change.Kind == "delete" {
+		return index.Doc{}, false, nil
+	}
+
+	body, err := json.Marshal(change.Columns)
+	if err != nil {
+		return index.Doc{}, false, err
+	}
+
+	return index.Doc{ID: fmt.Sprintf("%v", change.Columns["id"]), Body: body}, true, nil
+}
+
+// pollLoop is the fallback used when a logical replication connection can't
+// be established (e.g. the DB user lacks REPLICATION privilege, or no slots
+// are free): it repeats the existing -from-date indexing path on a timer.
+func (c *WatchCommand) pollLoop(ctx context.Context, pool *pgxpool.Pool, engine index.Engine, every time.Duration, batchSize int) error {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	lastRun := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			if err := c.pollOnce(ctx, pool, engine, lastRun, batchSize); err != nil {
+				return err
+			}
+			lastRun = now
+		}
+	}
+}
+
+func (c *WatchCommand) pollOnce(ctx context.Context, pool *pgxpool.Pool, engine index.Engine, since time.Time, batchSize int) error {
+	for _, indexConfig := range c.indexes {
+		entity, ok := registry.Get(indexConfig.Alias)
+		if !ok {
+			continue
+		}
+		indexer := index.New(engine, c.logger, entity.NewSource(pool), indexConfig.Name)
+
+		result, err := indexer.FromDate(ctx, since, batchSize)
+		if err != nil {
+			return err
+		}
+		c.logger.Printf("polled %s since=%v successful=%d failed=%d", indexConfig.Name, since, result.Successful, result.Failed)
+	}
+
+	return nil
+}
@@ -0,0 +1,26 @@
+package firm
+
+import (
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"github.com/ministryofjustice/opg-search-service/internal/index"
+	"github.com/ministryofjustice/opg-search-service/internal/index/registry"
+)
+
+func init() {
+	registry.Register(entity{})
+}
+
+// entity wires the firm index into the registry, replacing what used to be
+// a hard-coded "firm" branch in IndexCommand.
+type entity struct{}
+
+func (entity) Alias() string { return "firm" }
+
+func (entity) FlagName() string { return "firm" }
+
+func (entity) ConfigFunc() func() ([]byte, error) { return Config }
+
+func (entity) NewSource(pool *pgxpool.Pool) index.DataSource { return NewDB(pool) }
+
+func (entity) TableName() string { return "firms" }
@@ -0,0 +1,184 @@
+// Package bleve is an embedded index.Engine backed by blevesearch/bleve.
+// It needs no external cluster, which makes it a good fit for local
+// development and air-gapped installs.
+package bleve
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/ministryofjustice/opg-search-service/internal/index"
+)
+
+type Config struct {
+	// Dir is the directory bleve persists its index files under. Each
+	// index name gets its own subdirectory so multiple indices can share
+	// a Dir.
+	Dir string
+}
+
+// Engine is an index.Engine backed by on-disk bleve indices, one per index
+// name passed to Init.
+type Engine struct {
+	config Config
+
+	mu      sync.RWMutex
+	indices map[string]bleve.Index
+}
+
+func New(config Config) *Engine {
+	return &Engine{
+		config:  config,
+		indices: map[string]bleve.Index{},
+	}
+}
+
+func (e *Engine) Init(ctx context.Context, name string, config []byte) error {
+	path := filepath.Join(e.config.Dir, name)
+
+	mapping := bleve.NewIndexMapping()
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, mapping); err != nil {
+			return fmt.Errorf("bleve: invalid mapping for %s: %w", name, err)
+		}
+	}
+
+	idx, err := bleve.Open(path)
+	if err == bleve.ErrorIndexPathDoesNotExist {
+		if mkErr := os.MkdirAll(e.config.Dir, 0o755); mkErr != nil {
+			return mkErr
+		}
+		idx, err = bleve.New(path, mapping)
+	}
+	if err != nil {
+		return fmt.Errorf("bleve: open %s: %w", name, err)
+	}
+
+	e.mu.Lock()
+	e.indices[name] = idx
+	e.mu.Unlock()
+	return nil
+}
+
+func (e *Engine) Bulk(ctx context.Context, name string, docs []index.Doc) (index.Result, error) {
+	idx, err := e.get(name)
+	if err != nil {
+		return index.Result{}, err
+	}
+
+	batch := idx.NewBatch()
+	result := index.Result{}
+
+	for _, doc := range docs {
+		if doc.Body == nil {
+			batch.Delete(doc.ID)
+			result.Successful++
+			continue
+		}
+
+		var body map[string]interface{}
+		if err := json.Unmarshal(doc.Body, &body); err != nil {
+			result.Failed++
+			result.Errors = append(result.Errors, fmt.Errorf("bleve: decode doc %s: %w", doc.ID, err))
+			continue
+		}
+		if err := batch.Index(doc.ID, body); err != nil {
+			result.Failed++
+			result.Errors = append(result.Errors, err)
+			continue
+		}
+		result.Successful++
+	}
+
+	if batch.Size() > 0 {
+		if err := idx.Batch(batch); err != nil {
+			return index.Result{}, fmt.Errorf("bleve: apply batch for %s: %w", name, err)
+		}
+	}
+
+	return result, nil
+}
+
+func (e *Engine) Search(ctx context.Context, query index.Query) (index.Hits, error) {
+	idx, err := e.get(query.Index)
+	if err != nil {
+		return index.Hits{}, err
+	}
+
+	q := bleve.NewQueryStringQuery(query.Term)
+	req := bleve.NewSearchRequestOptions(q, query.Size, query.From, false)
+	req.Fields = []string{"*"}
+
+	res, err := idx.SearchInContext(ctx, req)
+	if err != nil {
+		return index.Hits{}, err
+	}
+
+	hits := index.Hits{Total: int(res.Total)}
+	for _, hit := range res.Hits {
+		hits.Hits = append(hits.Hits, hit.Fields)
+	}
+	return hits, nil
+}
+
+// AliasSwap has no bleve equivalent of an OpenSearch alias: callers should
+// instead point queries at newName directly once the backfill into it is
+// complete.
+func (e *Engine) AliasSwap(ctx context.Context, alias, newName string, oldNames []string) error {
+	return fmt.Errorf("bleve: alias swap is not supported, query %q directly instead of %q", newName, alias)
+}
+
+// SupportsAliasSwap is always false; see AliasSwap.
+func (e *Engine) SupportsAliasSwap() bool { return false }
+
+// Delete closes and removes name's on-disk index. The whole operation runs
+// under e.mu, the same lock Bulk/Search take to look up the index, so a
+// Delete can't close out from under a lookup that's still in flight.
+func (e *Engine) Delete(ctx context.Context, name string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	idx, ok := e.indices[name]
+	if !ok {
+		return nil // already gone, nothing to reclaim
+	}
+
+	if err := idx.Close(); err != nil {
+		return fmt.Errorf("bleve: close %s before delete: %w", name, err)
+	}
+	delete(e.indices, name)
+
+	if err := os.RemoveAll(filepath.Join(e.config.Dir, name)); err != nil {
+		return fmt.Errorf("bleve: delete %s: %w", name, err)
+	}
+	return nil
+}
+
+func (e *Engine) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var firstErr error
+	for name, idx := range e.indices {
+		if err := idx.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("bleve: close %s: %w", name, err)
+		}
+	}
+	return firstErr
+}
+
+func (e *Engine) get(name string) (bleve.Index, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	idx, ok := e.indices[name]
+	if !ok {
+		return nil, fmt.Errorf("bleve: index %q not initialised", name)
+	}
+	return idx, nil
+}
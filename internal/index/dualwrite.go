@@ -0,0 +1,39 @@
+package index
+
+import (
+	"context"
+	"fmt"
+)
+
+// dualWriteEngine fans Bulk writes for one index name out to two physical
+// index names on the same Engine, so live writes during a blue/green
+// backfill reach both the old and new generation until the alias swap
+// completes.
+type dualWriteEngine struct {
+	Engine
+	secondaryName string
+}
+
+// DualWrite wraps engine so that every Bulk call, whichever index name it
+// targets, is also applied to secondaryName, best-effort: a failed secondary
+// write is reported in the Result's Errors but doesn't fail the primary write.
+func DualWrite(engine Engine, secondaryName string) Engine {
+	return &dualWriteEngine{Engine: engine, secondaryName: secondaryName}
+}
+
+func (e *dualWriteEngine) Bulk(ctx context.Context, name string, docs []Doc) (Result, error) {
+	result, err := e.Engine.Bulk(ctx, name, docs)
+	if err != nil {
+		return result, err
+	}
+
+	secResult, secErr := e.Engine.Bulk(ctx, e.secondaryName, docs)
+	if secErr != nil {
+		result.Errors = append(result.Errors, fmt.Errorf("dual-write to %s: %w", e.secondaryName, secErr))
+	}
+	for _, docErr := range secResult.Errors {
+		result.Errors = append(result.Errors, fmt.Errorf("dual-write to %s: %w", e.secondaryName, docErr))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,342 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/ministryofjustice/opg-search-service/internal/index/checkpoint"
+)
+
+// Doc is a single document to be written to an Engine, keyed by its
+// document ID.
+type Doc struct {
+	ID   string
+	Body []byte
+}
+
+// Result is the outcome of a bulk indexing operation.
+type Result struct {
+	Successful int
+	Failed     int
+	Errors     []error
+}
+
+// Query is an engine-agnostic search request.
+type Query struct {
+	Index string
+	Term  string
+	From  int
+	Size  int
+}
+
+// Hits is the outcome of a Search against an Engine.
+type Hits struct {
+	Total int
+	Hits  []map[string]interface{}
+}
+
+// Engine is implemented by each supported search backend. IndexCommand and
+// Indexer talk to whichever Engine is configured rather than to a specific
+// backend client, so new backends can be added under internal/index/<name>
+// without touching call sites.
+type Engine interface {
+	Init(ctx context.Context, name string, config []byte) error
+	Bulk(ctx context.Context, name string, docs []Doc) (Result, error)
+	Search(ctx context.Context, query Query) (Hits, error)
+	AliasSwap(ctx context.Context, alias, newName string, oldNames []string) error
+
+	// SupportsAliasSwap reports whether AliasSwap actually cuts an alias
+	// over to a new index, rather than unconditionally failing. Callers
+	// doing a blue/green reindex should check this before starting a
+	// backfill, not after.
+	SupportsAliasSwap() bool
+
+	// Delete removes a physical index by name. Callers use this to reclaim
+	// a retired generation once manifest.Store.Prune has dropped its
+	// manifest row, so a blue/green reindex doesn't leak one index per run.
+	Delete(ctx context.Context, name string) error
+
+	Close() error
+}
+
+// IDRange is an inclusive range of record ids handled by a single shard.
+type IDRange struct {
+	From, To int
+}
+
+// Cursor streams Docs in batches from a DataSource without loading the
+// whole result set into memory, so a large backfill can be checkpointed
+// and resumed partway through. Callers must Close it once done.
+type Cursor interface {
+	// Next reads up to batchSize Docs. lastID and lastUpdatedAt describe
+	// the last row read, for checkpointing. ok is false once the cursor is
+	// exhausted, though the final call may still return docs alongside
+	// ok=false.
+	Next(ctx context.Context, batchSize int) (docs []Doc, lastID int, lastUpdatedAt time.Time, ok bool, err error)
+	Close() error
+}
+
+// DataSource reads records for a single entity (firm, person, ...) from the
+// database and streams them as Docs for Engine.Bulk.
+type DataSource interface {
+	// IDBounds returns the full id range present for this entity, so it can
+	// be split into shards for parallel indexing.
+	IDBounds(ctx context.Context) (min, to int, err error)
+
+	// ByIDCursor opens a cursor over ids in [from, to], ordered by id
+	// ascending, resuming after resumeAfterID if it is non-zero.
+	ByIDCursor(ctx context.Context, from, to, resumeAfterID int) (Cursor, error)
+
+	// FromDateCursor opens a cursor over records updated at or after from.
+	FromDateCursor(ctx context.Context, from time.Time) (Cursor, error)
+}
+
+// Indexer drives a DataSource's records into an Engine for a single index.
+type Indexer struct {
+	engine     Engine
+	logger     Logger
+	dataSource DataSource
+	indexName  string
+}
+
+// Logger is the subset of *logrus.Logger used by this package, kept small so
+// Indexer doesn't force a logging implementation on callers.
+type Logger interface {
+	Printf(format string, args ...interface{})
+	Println(args ...interface{})
+}
+
+func New(engine Engine, logger Logger, dataSource DataSource, indexName string) *Indexer {
+	return &Indexer{
+		engine:     engine,
+		logger:     logger,
+		dataSource: dataSource,
+		indexName:  indexName,
+	}
+}
+
+// RunOptions controls a parallel, checkpointed, rate-limited indexing run
+// of All or ByID.
+type RunOptions struct {
+	// Name identifies this run's checkpoints. It's the physical index name
+	// (IndexConfig.Name), not the alias, so a blue/green reindex's backfill
+	// into a brand-new generation starts from scratch instead of resuming
+	// from the outgoing generation's checkpoints.
+	Name string
+
+	// Workers is the number of shards read and bulked concurrently.
+	// runtime.GOMAXPROCS(0) is used if Workers is zero.
+	Workers int
+
+	// BatchSize is how many Docs are read from the Cursor, and bulked to
+	// the Engine, per round trip.
+	BatchSize int
+
+	// Restart discards any existing checkpoints for Name and indexes from
+	// the beginning instead of resuming.
+	Restart bool
+
+	// RateLimit caps throughput in docs/sec across all shards; zero means
+	// unlimited.
+	RateLimit float64
+
+	// Checkpoints persists per-shard progress after every successful bulk,
+	// so a crashed run can resume instead of starting over.
+	Checkpoints checkpoint.Store
+}
+
+// All indexes every record for this Indexer's DataSource, sharded across
+// opts.Workers workers and resumable via opts.Checkpoints.
+func (i *Indexer) All(ctx context.Context, opts RunOptions) (*Result, error) {
+	from, to, err := i.dataSource.IDBounds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return i.ByID(ctx, from, to, opts)
+}
+
+// ByID indexes records with id in [from, to], sharded across opts.Workers
+// workers and resumable via opts.Checkpoints.
+func (i *Indexer) ByID(ctx context.Context, from, to int, opts RunOptions) (*Result, error) {
+	workers := opts.Workers
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	if opts.Restart {
+		if err := opts.Checkpoints.Reset(ctx, opts.Name); err != nil {
+			return nil, fmt.Errorf("reset checkpoints: %w", err)
+		}
+	}
+
+	var limiter *rate.Limiter
+	if opts.RateLimit > 0 {
+		limiter = rate.NewLimiter(rate.Limit(opts.RateLimit), opts.BatchSize)
+	}
+
+	shards := shardIDRange(from, to, workers)
+	results := make([]Result, len(shards))
+	errs := make([]error, len(shards))
+
+	var wg sync.WaitGroup
+	for n, idRange := range shards {
+		wg.Add(1)
+		go func(shard int, idRange IDRange) {
+			defer wg.Done()
+			results[shard], errs[shard] = i.indexShard(ctx, shard, idRange, opts, limiter)
+		}(n, idRange)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return aggregate(results), nil
+}
+
+// FromDate indexes records updated at or after from. It isn't sharded or
+// checkpointed: incremental catch-up runs are small enough that resuming a
+// crashed run from the same -from-date is cheap.
+func (i *Indexer) FromDate(ctx context.Context, from time.Time, batchSize int) (*Result, error) {
+	cursor, err := i.dataSource.FromDateCursor(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close() //nolint:errcheck // no need to check error when closing a read-only cursor
+
+	total := &Result{}
+	for {
+		docs, _, _, ok, err := cursor.Next(ctx, batchSize)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(docs) > 0 {
+			result, err := i.bulk(ctx, docs)
+			if err != nil {
+				return nil, err
+			}
+			total.Successful += result.Successful
+			total.Failed += result.Failed
+			total.Errors = append(total.Errors, result.Errors...)
+		}
+
+		if !ok {
+			break
+		}
+	}
+
+	return total, nil
+}
+
+// indexShard streams and bulks a single shard's id range, checkpointing
+// after every successful bulk so the shard can resume from where it left
+// off if the run is interrupted.
+func (i *Indexer) indexShard(ctx context.Context, shard int, idRange IDRange, opts RunOptions, limiter *rate.Limiter) (Result, error) {
+	resumeAfterID := 0
+	if !opts.Restart {
+		lastID, ok, err := opts.Checkpoints.LastID(ctx, opts.Name, idRange.From, idRange.To)
+		if err != nil {
+			return Result{}, fmt.Errorf("shard %d: load checkpoint: %w", shard, err)
+		}
+		if ok {
+			resumeAfterID = lastID
+		} else {
+			i.logger.Printf("shard %d [%d,%d]: no checkpoint for these exact bounds, indexing from the start of the range (expected if -workers changed since the last run)", shard, idRange.From, idRange.To)
+		}
+	}
+
+	cursor, err := i.dataSource.ByIDCursor(ctx, idRange.From, idRange.To, resumeAfterID)
+	if err != nil {
+		return Result{}, fmt.Errorf("shard %d: %w", shard, err)
+	}
+	defer cursor.Close() //nolint:errcheck // no need to check error when closing a read-only cursor
+
+	total := Result{}
+	for {
+		docs, lastID, lastUpdatedAt, ok, err := cursor.Next(ctx, opts.BatchSize)
+		if err != nil {
+			return total, fmt.Errorf("shard %d: %w", shard, err)
+		}
+
+		if len(docs) > 0 {
+			if limiter != nil {
+				if err := limiter.WaitN(ctx, len(docs)); err != nil {
+					return total, fmt.Errorf("shard %d: rate limit: %w", shard, err)
+				}
+			}
+
+			result, err := i.bulk(ctx, docs)
+			if err != nil {
+				return total, fmt.Errorf("shard %d: %w", shard, err)
+			}
+			total.Successful += result.Successful
+			total.Failed += result.Failed
+			total.Errors = append(total.Errors, result.Errors...)
+
+			if err := opts.Checkpoints.Set(ctx, opts.Name, idRange.From, idRange.To, lastID, lastUpdatedAt); err != nil {
+				return total, fmt.Errorf("shard %d: save checkpoint: %w", shard, err)
+			}
+		}
+
+		if !ok {
+			break
+		}
+	}
+
+	i.logger.Printf("shard %d done successful=%d failed=%d", shard, total.Successful, total.Failed)
+	return total, nil
+}
+
+func (i *Indexer) bulk(ctx context.Context, docs []Doc) (*Result, error) {
+	result, err := i.engine.Bulk(ctx, i.indexName, docs)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// shardIDRange splits [from, to] into up to `workers` contiguous, roughly
+// equal IDRanges.
+func shardIDRange(from, to, workers int) []IDRange {
+	if workers < 1 {
+		workers = 1
+	}
+	if to < from {
+		return nil
+	}
+
+	span := to - from + 1
+	size := (span + workers - 1) / workers
+	if size < 1 {
+		size = 1
+	}
+
+	var shards []IDRange
+	for start := from; start <= to; start += size {
+		end := start + size - 1
+		if end > to {
+			end = to
+		}
+		shards = append(shards, IDRange{From: start, To: end})
+	}
+	return shards
+}
+
+func aggregate(results []Result) *Result {
+	total := &Result{}
+	for _, r := range results {
+		total.Successful += r.Successful
+		total.Failed += r.Failed
+		total.Errors = append(total.Errors, r.Errors...)
+	}
+	return total
+}
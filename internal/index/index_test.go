@@ -0,0 +1,56 @@
+package index
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestShardIDRange(t *testing.T) {
+	cases := []struct {
+		name              string
+		from, to, workers int
+		want              []IDRange
+	}{
+		{"empty range", 10, 5, 4, nil},
+		{"single id", 1, 1, 4, []IDRange{{From: 1, To: 1}}},
+		{"evenly divisible", 1, 10, 2, []IDRange{{From: 1, To: 5}, {From: 6, To: 10}}},
+		{"not evenly divisible", 1, 10, 3, []IDRange{{From: 1, To: 4}, {From: 5, To: 8}, {From: 9, To: 10}}},
+		{"more workers than ids", 1, 3, 10, []IDRange{{From: 1, To: 1}, {From: 2, To: 2}, {From: 3, To: 3}}},
+		{"zero workers treated as one", 1, 3, 0, []IDRange{{From: 1, To: 3}}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := shardIDRange(c.from, c.to, c.workers)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("shardIDRange(%d, %d, %d) = %v, want %v", c.from, c.to, c.workers, got, c.want)
+			}
+		})
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	err1 := errors.New("shard 0 failed")
+	err2 := errors.New("shard 1 failed")
+
+	results := []Result{
+		{Successful: 3, Failed: 1, Errors: []error{err1}},
+		{Successful: 5, Failed: 0},
+		{Successful: 2, Failed: 2, Errors: []error{err2}},
+	}
+
+	got := aggregate(results)
+
+	want := &Result{Successful: 10, Failed: 3, Errors: []error{err1, err2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("aggregate(%v) = %+v, want %+v", results, got, want)
+	}
+}
+
+func TestAggregateEmpty(t *testing.T) {
+	got := aggregate(nil)
+	if got.Successful != 0 || got.Failed != 0 || len(got.Errors) != 0 {
+		t.Errorf("aggregate(nil) = %+v, want zero Result", got)
+	}
+}
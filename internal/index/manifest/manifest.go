@@ -0,0 +1,208 @@
+// Package manifest persists the alias -> index-name history behind a
+// blue/green reindex, so a rollback can flip an alias back to its previous
+// generation even after the process that performed the swap has exited, and
+// so a concurrently running WatchCommand can tell whether live writes need
+// to be dual-written into an in-progress reindex's target index.
+package manifest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Status is where a generation is in its blue/green lifecycle.
+type Status string
+
+const (
+	// StatusPending is set once a new generation's index has been created,
+	// just before its backfill starts, and stays until the alias has been
+	// swapped onto it.
+	StatusPending Status = "pending"
+
+	// StatusActive is the generation the alias currently points at.
+	StatusActive Status = "active"
+
+	// StatusRetired is a generation the alias used to point at. It's kept
+	// around, unswapped, until GraceUntil so cmd.RollbackCommand can flip
+	// back to it.
+	StatusRetired Status = "retired"
+)
+
+// Entry records one index generation registered for an alias.
+type Entry struct {
+	Alias       string
+	Name        string
+	Status      Status
+	CreatedAt   time.Time
+	ActivatedAt *time.Time
+	RetiredAt   *time.Time
+	GraceUntil  *time.Time
+}
+
+// Store is implemented against the application database; see PostgresStore.
+type Store interface {
+	// History returns every generation registered for alias, most recently
+	// created first.
+	History(ctx context.Context, alias string) ([]Entry, error)
+
+	// Current returns the active generation's index name, if any.
+	Current(ctx context.Context, alias string) (name string, ok bool, err error)
+
+	// Pending returns the in-progress reindex's target index name, if any.
+	Pending(ctx context.Context, alias string) (name string, ok bool, err error)
+
+	// BeginReindex registers name as alias's pending generation, ahead of
+	// its backfill starting.
+	BeginReindex(ctx context.Context, alias, name string) error
+
+	// Activate flips the pending generation name to active, retiring
+	// whichever generation was previously active and marking it eligible
+	// for deletion once grace has elapsed.
+	Activate(ctx context.Context, alias, name string, grace time.Duration) error
+
+	// Prune drops manifest rows for retired generations beyond the most
+	// recent keep, once their grace period has elapsed, and returns the
+	// names of the generations it dropped. It does not touch the underlying
+	// search indices themselves — callers are responsible for reclaiming
+	// them (see index.Engine.Delete) using the returned names.
+	Prune(ctx context.Context, alias string, keep int) (pruned []string, err error)
+}
+
+// PostgresStore is a Store backed by a search_index_manifest table, created
+// by a migration such as:
+//
+//	CREATE TABLE search_index_manifest (
+//		alias        text NOT NULL,
+//		name         text NOT NULL,
+//		status       text NOT NULL,
+//		created_at   timestamptz NOT NULL,
+//		activated_at timestamptz,
+//		retired_at   timestamptz,
+//		grace_until  timestamptz,
+//		PRIMARY KEY (alias, name)
+//	);
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresStore(pool *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{pool: pool}
+}
+
+func (s *PostgresStore) History(ctx context.Context, alias string) ([]Entry, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT alias, name, status, created_at, activated_at, retired_at, grace_until
+		 FROM search_index_manifest
+		 WHERE alias = $1
+		 ORDER BY created_at DESC`,
+		alias)
+	if err != nil {
+		return nil, fmt.Errorf("manifest: query history for %s: %w", alias, err)
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.Alias, &e.Name, &e.Status, &e.CreatedAt, &e.ActivatedAt, &e.RetiredAt, &e.GraceUntil); err != nil {
+			return nil, fmt.Errorf("manifest: scan history for %s: %w", alias, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (s *PostgresStore) Current(ctx context.Context, alias string) (string, bool, error) {
+	return s.nameByStatus(ctx, alias, StatusActive)
+}
+
+func (s *PostgresStore) Pending(ctx context.Context, alias string) (string, bool, error) {
+	return s.nameByStatus(ctx, alias, StatusPending)
+}
+
+func (s *PostgresStore) nameByStatus(ctx context.Context, alias string, status Status) (string, bool, error) {
+	var name string
+	err := s.pool.QueryRow(ctx,
+		`SELECT name FROM search_index_manifest WHERE alias = $1 AND status = $2`,
+		alias, status).Scan(&name)
+	if err == pgx.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("manifest: query %s generation for %s: %w", status, alias, err)
+	}
+	return name, true, nil
+}
+
+func (s *PostgresStore) BeginReindex(ctx context.Context, alias, name string) error {
+	_, err := s.pool.Exec(ctx,
+		`INSERT INTO search_index_manifest (alias, name, status, created_at)
+		 VALUES ($1, $2, $3, now())
+		 ON CONFLICT (alias, name) DO UPDATE SET status = $3`,
+		alias, name, StatusPending)
+	if err != nil {
+		return fmt.Errorf("manifest: begin reindex %s for %s: %w", name, alias, err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) Activate(ctx context.Context, alias, name string, grace time.Duration) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("manifest: begin: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // no-op once committed
+
+	if _, err := tx.Exec(ctx,
+		`UPDATE search_index_manifest
+		 SET status = $3, retired_at = now(), grace_until = now() + $4
+		 WHERE alias = $1 AND status = $2`,
+		alias, StatusActive, StatusRetired, grace); err != nil {
+		return fmt.Errorf("manifest: retire previous generation for %s: %w", alias, err)
+	}
+
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO search_index_manifest (alias, name, status, created_at, activated_at)
+		 VALUES ($1, $2, $3, now(), now())
+		 ON CONFLICT (alias, name) DO UPDATE SET status = $3, activated_at = now(), retired_at = NULL, grace_until = NULL`,
+		alias, name, StatusActive); err != nil {
+		return fmt.Errorf("manifest: activate %s for %s: %w", name, alias, err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (s *PostgresStore) Prune(ctx context.Context, alias string, keep int) ([]string, error) {
+	rows, err := s.pool.Query(ctx,
+		`DELETE FROM search_index_manifest
+		 WHERE alias = $1
+		 AND status = $2
+		 AND grace_until < now()
+		 AND name NOT IN (
+			SELECT name FROM search_index_manifest
+			WHERE alias = $1
+			AND status = $2
+			ORDER BY created_at DESC
+			LIMIT $3
+		 )
+		 RETURNING name`,
+		alias, StatusRetired, keep)
+	if err != nil {
+		return nil, fmt.Errorf("manifest: prune %s: %w", alias, err)
+	}
+	defer rows.Close()
+
+	var pruned []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("manifest: scan pruned name for %s: %w", alias, err)
+		}
+		pruned = append(pruned, name)
+	}
+	return pruned, rows.Err()
+}
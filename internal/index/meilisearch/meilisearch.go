@@ -0,0 +1,187 @@
+// Package meilisearch is an index.Engine backed by a Meilisearch server,
+// reachable over its HTTP REST API.
+package meilisearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ministryofjustice/opg-search-service/internal/index"
+)
+
+type Config struct {
+	URL    string
+	APIKey string
+}
+
+// Engine is an index.Engine backed by Meilisearch's HTTP REST API.
+type Engine struct {
+	client *http.Client
+	config Config
+}
+
+func New(config Config) *Engine {
+	return &Engine{
+		client: &http.Client{},
+		config: config,
+	}
+}
+
+func (e *Engine) Init(ctx context.Context, name string, config []byte) error {
+	body, err := json.Marshal(map[string]string{"uid": name, "primaryKey": "id"})
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.do(ctx, http.MethodPost, "/indexes", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck // no need to check error when closing response body
+
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusConflict {
+		return fmt.Errorf("meilisearch: create index %s: %s", name, resp.Status)
+	}
+
+	if len(config) == 0 {
+		return nil
+	}
+
+	settingsResp, err := e.do(ctx, http.MethodPatch, "/indexes/"+name+"/settings", config)
+	if err != nil {
+		return err
+	}
+	defer settingsResp.Body.Close() //nolint:errcheck // no need to check error when closing response body
+
+	if settingsResp.StatusCode >= 300 {
+		return fmt.Errorf("meilisearch: apply settings for %s: %s", name, settingsResp.Status)
+	}
+	return nil
+}
+
+func (e *Engine) Bulk(ctx context.Context, name string, docs []index.Doc) (index.Result, error) {
+	result := index.Result{}
+
+	var upserts []json.RawMessage
+	var deleteIDs []string
+	for _, doc := range docs {
+		if doc.Body == nil {
+			deleteIDs = append(deleteIDs, doc.ID)
+			continue
+		}
+		upserts = append(upserts, doc.Body)
+	}
+
+	if len(upserts) > 0 {
+		body, err := json.Marshal(upserts)
+		if err != nil {
+			return index.Result{}, err
+		}
+
+		resp, err := e.do(ctx, http.MethodPut, "/indexes/"+name+"/documents", body)
+		if err != nil {
+			return index.Result{}, err
+		}
+		defer resp.Body.Close() //nolint:errcheck // no need to check error when closing response body
+
+		if resp.StatusCode >= 300 {
+			return index.Result{}, fmt.Errorf("meilisearch: bulk index %s: %s", name, resp.Status)
+		}
+		// Meilisearch applies document updates asynchronously via a task
+		// queue; a 2xx response here means the task was accepted, not yet
+		// applied.
+		result.Successful += len(upserts)
+	}
+
+	if len(deleteIDs) > 0 {
+		body, err := json.Marshal(deleteIDs)
+		if err != nil {
+			return index.Result{}, err
+		}
+
+		resp, err := e.do(ctx, http.MethodPost, "/indexes/"+name+"/documents/delete-batch", body)
+		if err != nil {
+			return index.Result{}, err
+		}
+		defer resp.Body.Close() //nolint:errcheck // no need to check error when closing response body
+
+		if resp.StatusCode >= 300 {
+			return index.Result{}, fmt.Errorf("meilisearch: bulk delete %s: %s", name, resp.Status)
+		}
+		result.Successful += len(deleteIDs)
+	}
+
+	return result, nil
+}
+
+func (e *Engine) Search(ctx context.Context, query index.Query) (index.Hits, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"q":      query.Term,
+		"offset": query.From,
+		"limit":  query.Size,
+	})
+	if err != nil {
+		return index.Hits{}, err
+	}
+
+	resp, err := e.do(ctx, http.MethodPost, "/indexes/"+query.Index+"/search", body)
+	if err != nil {
+		return index.Hits{}, err
+	}
+	defer resp.Body.Close() //nolint:errcheck // no need to check error when closing response body
+
+	var parsed struct {
+		EstimatedTotalHits int                      `json:"estimatedTotalHits"`
+		Hits               []map[string]interface{} `json:"hits"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return index.Hits{}, err
+	}
+
+	return index.Hits{Total: parsed.EstimatedTotalHits, Hits: parsed.Hits}, nil
+}
+
+// AliasSwap has no Meilisearch equivalent of an OpenSearch alias: callers
+// should instead swap which index name is queried at the application layer.
+func (e *Engine) AliasSwap(ctx context.Context, alias, newName string, oldNames []string) error {
+	return fmt.Errorf("meilisearch: alias swap is not supported, point %q at %q directly", alias, newName)
+}
+
+// SupportsAliasSwap is always false; see AliasSwap.
+func (e *Engine) SupportsAliasSwap() bool { return false }
+
+func (e *Engine) Delete(ctx context.Context, name string) error {
+	resp, err := e.do(ctx, http.MethodDelete, "/indexes/"+name, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck // no need to check error when closing response body
+
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("meilisearch: delete index %s: %s", name, resp.Status)
+	}
+	return nil
+}
+
+func (e *Engine) Close() error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+func (e *Engine) do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(e.config.URL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.config.APIKey)
+	}
+	req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return e.client.Do(req)
+}
@@ -0,0 +1,243 @@
+// Package opensearch is the default index.Engine backed by an OpenSearch
+// (or Elasticsearch-compatible) cluster, reachable over its HTTP REST API.
+package opensearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ministryofjustice/opg-search-service/internal/index"
+)
+
+type Config struct {
+	URL      string
+	Username string
+	Password string
+}
+
+// Engine is an index.Engine backed by OpenSearch's HTTP REST API.
+type Engine struct {
+	client *http.Client
+	config Config
+}
+
+func New(config Config) *Engine {
+	return &Engine{
+		client: &http.Client{},
+		config: config,
+	}
+}
+
+func (e *Engine) Init(ctx context.Context, alias string, config []byte) error {
+	req, err := e.newRequest(ctx, http.MethodPut, "/"+alias, config)
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck // no need to check error when closing response body
+
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusBadRequest {
+		return fmt.Errorf("opensearch: create index %s: %s", alias, resp.Status)
+	}
+	return nil
+}
+
+func (e *Engine) Bulk(ctx context.Context, name string, docs []index.Doc) (index.Result, error) {
+	var body bytes.Buffer
+	for _, doc := range docs {
+		// A nil Body is the convention for a delete of that document ID,
+		// used by internal/index/stream when replaying a DELETE event.
+		if doc.Body == nil {
+			action, err := json.Marshal(map[string]interface{}{
+				"delete": map[string]string{"_index": name, "_id": doc.ID},
+			})
+			if err != nil {
+				return index.Result{}, err
+			}
+			body.Write(action)
+			body.WriteByte('\n')
+			continue
+		}
+
+		action, err := json.Marshal(map[string]interface{}{
+			"index": map[string]string{"_index": name, "_id": doc.ID},
+		})
+		if err != nil {
+			return index.Result{}, err
+		}
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(doc.Body)
+		body.WriteByte('\n')
+	}
+
+	req, err := e.newRequest(ctx, http.MethodPost, "/_bulk", body.Bytes())
+	if err != nil {
+		return index.Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return index.Result{}, err
+	}
+	defer resp.Body.Close() //nolint:errcheck // no need to check error when closing response body
+
+	return parseBulkResponse(resp.Body)
+}
+
+func parseBulkResponse(r io.Reader) (index.Result, error) {
+	type itemResult struct {
+		Status int    `json:"status"`
+		Error  string `json:"error"`
+	}
+	var parsed struct {
+		Items []struct {
+			Index  *itemResult `json:"index"`
+			Delete *itemResult `json:"delete"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(r).Decode(&parsed); err != nil {
+		return index.Result{}, err
+	}
+
+	result := index.Result{}
+	for _, item := range parsed.Items {
+		res := item.Index
+		if res == nil {
+			res = item.Delete
+		}
+		if res == nil {
+			continue
+		}
+		if res.Status >= 200 && res.Status < 300 {
+			result.Successful++
+		} else {
+			result.Failed++
+			result.Errors = append(result.Errors, fmt.Errorf("opensearch: %s", res.Error))
+		}
+	}
+	return result, nil
+}
+
+func (e *Engine) Search(ctx context.Context, query index.Query) (index.Hits, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"from": query.From,
+		"size": query.Size,
+		"query": map[string]interface{}{
+			"query_string": map[string]string{"query": query.Term},
+		},
+	})
+	if err != nil {
+		return index.Hits{}, err
+	}
+
+	req, err := e.newRequest(ctx, http.MethodPost, "/"+query.Index+"/_search", body)
+	if err != nil {
+		return index.Hits{}, err
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return index.Hits{}, err
+	}
+	defer resp.Body.Close() //nolint:errcheck // no need to check error when closing response body
+
+	var parsed struct {
+		Hits struct {
+			Total struct {
+				Value int `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Source map[string]interface{} `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return index.Hits{}, err
+	}
+
+	hits := index.Hits{Total: parsed.Hits.Total.Value}
+	for _, h := range parsed.Hits.Hits {
+		hits.Hits = append(hits.Hits, h.Source)
+	}
+	return hits, nil
+}
+
+func (e *Engine) AliasSwap(ctx context.Context, alias, newName string, oldNames []string) error {
+	actions := []map[string]interface{}{
+		{"add": map[string]string{"index": newName, "alias": alias}},
+	}
+	for _, oldName := range oldNames {
+		actions = append(actions, map[string]interface{}{
+			"remove": map[string]string{"index": oldName, "alias": alias},
+		})
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"actions": actions})
+	if err != nil {
+		return err
+	}
+
+	req, err := e.newRequest(ctx, http.MethodPost, "/_aliases", body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck // no need to check error when closing response body
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("opensearch: alias swap %s: %s", alias, resp.Status)
+	}
+	return nil
+}
+
+// SupportsAliasSwap is always true: OpenSearch aliases are how this engine
+// implements AliasSwap.
+func (e *Engine) SupportsAliasSwap() bool { return true }
+
+func (e *Engine) Delete(ctx context.Context, name string) error {
+	req, err := e.newRequest(ctx, http.MethodDelete, "/"+name, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck // no need to check error when closing response body
+
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("opensearch: delete index %s: %s", name, resp.Status)
+	}
+	return nil
+}
+
+func (e *Engine) Close() error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+func (e *Engine) newRequest(ctx context.Context, method, path string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(e.config.URL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.config.Username != "" {
+		req.SetBasicAuth(e.config.Username, e.config.Password)
+	}
+	return req, nil
+}
@@ -0,0 +1,62 @@
+// Package registry lets entity packages (firm, person, ...) register
+// themselves as indexable document types, so IndexCommand doesn't need a
+// hard-coded branch per entity.
+package registry
+
+import (
+	"fmt"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/ministryofjustice/opg-search-service/internal/index"
+)
+
+// Entity is a document type IndexCommand knows how to index.
+type Entity interface {
+	// Alias is the index alias this entity indexes into (e.g. "firm").
+	Alias() string
+
+	// ConfigFunc returns the function used to build this entity's engine
+	// mapping config, suitable for passing to cmd.NewIndexConfig.
+	ConfigFunc() func() ([]byte, error)
+
+	// NewSource builds the index.DataSource that reads this entity's rows
+	// from the given DB pool.
+	NewSource(pool *pgxpool.Pool) index.DataSource
+
+	// FlagName is the -<name> flag IndexCommand generates to restrict a run
+	// to this entity alone.
+	FlagName() string
+
+	// TableName is the Postgres table WatchCommand subscribes to for this
+	// entity's logical replication changes.
+	TableName() string
+}
+
+var entities []Entity
+
+// Register adds an Entity to the registry. Entity packages call this from
+// an init() function (see internal/firm/entity.go, internal/person/entity.go)
+// so importing them for their side effect is enough to wire them up.
+func Register(e Entity) {
+	for _, existing := range entities {
+		if existing.Alias() == e.Alias() {
+			panic(fmt.Sprintf("registry: entity %q already registered", e.Alias()))
+		}
+	}
+	entities = append(entities, e)
+}
+
+// All returns every registered Entity, in registration order.
+func All() []Entity {
+	return entities
+}
+
+// Get looks up a registered Entity by alias.
+func Get(alias string) (Entity, bool) {
+	for _, e := range entities {
+		if e.Alias() == alias {
+			return e, true
+		}
+	}
+	return nil, false
+}
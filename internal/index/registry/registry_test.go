@@ -0,0 +1,71 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/ministryofjustice/opg-search-service/internal/index"
+)
+
+type fakeEntity struct {
+	alias string
+}
+
+func (e fakeEntity) Alias() string    { return e.alias }
+func (e fakeEntity) FlagName() string { return e.alias }
+func (e fakeEntity) ConfigFunc() func() ([]byte, error) {
+	return func() ([]byte, error) { return nil, nil }
+}
+func (e fakeEntity) NewSource(pool *pgxpool.Pool) index.DataSource { return nil }
+func (e fakeEntity) TableName() string                             { return e.alias + "s" }
+
+func withCleanRegistry(t *testing.T) {
+	t.Helper()
+	saved := entities
+	entities = nil
+	t.Cleanup(func() { entities = saved })
+}
+
+func TestRegisterAndGet(t *testing.T) {
+	withCleanRegistry(t)
+
+	Register(fakeEntity{alias: "firm"})
+	Register(fakeEntity{alias: "person"})
+
+	e, ok := Get("firm")
+	if !ok || e.Alias() != "firm" {
+		t.Fatalf("Get(firm) = %v, %v", e, ok)
+	}
+
+	if _, ok := Get("unknown"); ok {
+		t.Fatal("Get(unknown) should not be found")
+	}
+}
+
+func TestAll(t *testing.T) {
+	withCleanRegistry(t)
+
+	Register(fakeEntity{alias: "firm"})
+	Register(fakeEntity{alias: "person"})
+
+	all := All()
+	if len(all) != 2 {
+		t.Fatalf("All() = %d entities, want 2", len(all))
+	}
+	if all[0].Alias() != "firm" || all[1].Alias() != "person" {
+		t.Fatalf("All() = %v, want registration order", all)
+	}
+}
+
+func TestRegisterDuplicateAliasPanics(t *testing.T) {
+	withCleanRegistry(t)
+
+	Register(fakeEntity{alias: "firm"})
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Register with a duplicate alias should panic")
+		}
+	}()
+	Register(fakeEntity{alias: "firm"})
+}